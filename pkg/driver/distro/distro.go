@@ -18,6 +18,10 @@ package driverdistro
 
 import (
 	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -25,12 +29,22 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/pkg/homedir"
 	"github.com/falcosecurity/driverkit/pkg/kernelrelease"
+	"github.com/jedisct1/go-minisign"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/net/context"
 	"gopkg.in/ini.v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
 
 	"github.com/falcosecurity/falcoctl/internal/utils"
 	drivertype "github.com/falcosecurity/falcoctl/pkg/driver/type"
@@ -53,8 +67,18 @@ var ErrUnsupported = errors.New("failed to determine distro")
 type Distro interface {
 	init(kr kernelrelease.KernelRelease, id string, cfg *ini.File) error    // private
 	FixupKernel(kr kernelrelease.KernelRelease) kernelrelease.KernelRelease // private
-	customizeBuild(ctx context.Context, printer *output.Printer, driverType drivertype.DriverType,
+	// DownloadKernelSources downloads and extracts the kernel headers/sources needed to
+	// build a driver for kr, returning the environment (at minimum KERNELDIR) that
+	// customizeBuild and driverType.Build expect. It is a standalone phase, decoupled
+	// from Build, so that callers can pre-stage a kernel tree on their own, e.g. to
+	// cache it across driver builds or to prepare it once on an air-gapped host and
+	// copy the resulting tree elsewhere.
+	DownloadKernelSources(ctx context.Context, printer *output.Printer,
 		kr kernelrelease.KernelRelease, hostRoot string) (map[string]string, error)
+	// customizeBuild applies any distro/driverType-specific tweaks to the environment
+	// returned by DownloadKernelSources before driverType.Build is invoked.
+	customizeBuild(ctx context.Context, printer *output.Printer, driverType drivertype.DriverType,
+		kr kernelrelease.KernelRelease, hostRoot string, env map[string]string) (map[string]string, error)
 	PreferredDriver(kr kernelrelease.KernelRelease) drivertype.DriverType
 	fmt.Stringer
 }
@@ -147,6 +171,10 @@ func copyDataToLocalPath(destination string, src io.Reader) error {
 }
 
 // Build will try to build the desired driver for the specified distro and kernel release.
+// env may carry caller-supplied overrides, such as a pre-existing KERNELDIR pointing at
+// an already prepared kernel tree (e.g. one produced by a prior DownloadKernelSources
+// call); if unset there, the KERNELDIR process environment variable is honored too. In
+// either case the download step is skipped entirely and the kernel tree is used as-is.
 //
 //nolint:gocritic // the method shall not be able to modify kr
 func Build(ctx context.Context,
@@ -157,8 +185,28 @@ func Build(ctx context.Context,
 	driverType drivertype.DriverType,
 	driverVer string,
 	hostRoot string,
+	env map[string]string,
 ) (string, error) {
-	env, err := d.customizeBuild(ctx, printer, driverType, kr, hostRoot)
+	if env == nil {
+		env = make(map[string]string)
+	}
+	if env[kernelDirEnv] == "" {
+		env[kernelDirEnv] = os.Getenv(kernelDirEnv)
+	}
+
+	if env[kernelDirEnv] != "" {
+		printer.Logger.Info("Using pre-existing kernel sources, skipping download.",
+			printer.Logger.Args(kernelDirEnv, env[kernelDirEnv]))
+	} else {
+		downloaded, err := d.DownloadKernelSources(ctx, printer, kr, hostRoot)
+		if err != nil {
+			return "", err
+		}
+		for k, v := range downloaded {
+			env[k] = v
+		}
+	}
+	env, err := d.customizeBuild(ctx, printer, driverType, kr, hostRoot, env)
 	if err != nil {
 		return "", err
 	}
@@ -180,7 +228,354 @@ func Build(ctx context.Context,
 	return filePath, copyDataToLocalPath(filePath, f)
 }
 
-// Download will try to download drivers for a distro trying specified repos.
+// RepoConfig describes a single prebuilt-driver repository to try during Download.
+type RepoConfig struct {
+	// URL is the HTTPS base URL of the repository, eg the default Falco driver repo, or
+	// an OCI repository reference prefixed with "oci://" or "registry://".
+	URL string
+	// MinisignPublicKey, when set, is the base64-encoded public key (the second line of
+	// a key pair generated by `minisign -G`) used to verify the minisign signature
+	// published as "<url>.minisig" alongside an HTTPS driver artifact. When empty, only
+	// the SHA-256 checksum is verified. OCI repos do not use it: their artifacts are
+	// checked only against the manifest digests.
+	MinisignPublicKey string
+}
+
+// verifyChecksum fetches the "<url>.sha256" sidecar published next to url and checks
+// that it matches the SHA-256 digest of data.
+func verifyChecksum(ctx context.Context, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+".sha256", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unable to fetch checksum for %s: status %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum response for %s", url)
+	}
+	expected := fields[0]
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, expected, actual)
+	}
+	return nil
+}
+
+// verifyMinisignSignature fetches the "<url>.minisig" sidecar published next to url and
+// checks it against data using publicKey: the base64-encoded public key minisign prints
+// on the second line of a key pair generated by `minisign -G`. This verifies real
+// minisign signatures (e.g. ones produced by `minisign -S -s key.sec -m driver.ko`), not
+// a bespoke lookalike scheme.
+func verifyMinisignSignature(ctx context.Context, url string, data []byte, publicKey string) error {
+	pubKey, err := minisign.NewPublicKey(publicKey)
+	if err != nil {
+		return fmt.Errorf("invalid minisign public key for %s: %w", url, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+".minisig", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("unable to fetch signature for %s: status %d", url, resp.StatusCode)
+	}
+	sigFile, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	sig, err := minisign.DecodeSignature(string(sigFile))
+	if err != nil {
+		return fmt.Errorf("invalid minisign signature for %s: %w", url, err)
+	}
+	if ok, err := pubKey.Verify(data, sig); err != nil || !ok {
+		return fmt.Errorf("signature verification failed for %s: %v", url, err)
+	}
+	return nil
+}
+
+// ociSchemes are the repo URL prefixes that route Download through the OCI registry
+// path instead of the plain HTTPS one.
+var ociSchemes = []string{"oci://", "registry://"}
+
+func ociReference(repo string) (string, bool) {
+	for _, scheme := range ociSchemes {
+		if strings.HasPrefix(repo, scheme) {
+			return strings.TrimPrefix(repo, scheme), true
+		}
+	}
+	return "", false
+}
+
+// toOCITag turns driverFileName into a valid, deterministic OCI tag, so that the same
+// distro/kernel/driverType combination always resolves to the same artifact tag.
+func toOCITag(driverFileName string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, driverFileName)
+}
+
+// newOCIRepository resolves ref against an authenticated registry client, sourcing
+// credentials the same way plugin/rulesfile OCI pulls do elsewhere in falcoctl: from
+// the user's Docker credential store (docker login, credential helpers, or a plain
+// config.json), so private registries work instead of only ever pulling anonymously.
+func newOCIRepository(ref string) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(ref)
+	if err != nil {
+		return nil, err
+	}
+	store, err := credentials.NewStoreFromDocker(credentials.StoreOptions{})
+	if err != nil {
+		return nil, err
+	}
+	repo.Client = &auth.Client{
+		Client:     http.DefaultClient,
+		Cache:      auth.NewCache(),
+		Credential: credentials.Credential(store),
+	}
+	return repo, nil
+}
+
+// fetchOCIArtifactLayer copies the manifest tagged ref from src into dst (oras.Copy also
+// pulls every blob the manifest references, verifying each one's digest as it goes), then
+// returns the bytes of the manifest's first layer: the actual driver artifact, as opposed
+// to the manifest document itself. src is a parameter rather than always being a live
+// registry so tests can exercise this against an in-memory store.
+func fetchOCIArtifactLayer(ctx context.Context, src oras.ReadOnlyTarget, dst oras.Target, ref string) ([]byte, error) {
+	manifestDesc, err := oras.Copy(ctx, src, ref, dst, ref, oras.DefaultCopyOptions)
+	if err != nil {
+		return nil, err
+	}
+	manifestBytes, err := content.FetchAll(ctx, dst, manifestDesc)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing OCI manifest for %s: %w", ref, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("OCI artifact %s has no layers", ref)
+	}
+	return content.FetchAll(ctx, dst, manifest.Layers[0])
+}
+
+// pullOCIDriver pulls the single-layer driver artifact tagged ref (e.g.
+// "ghcr.io/falcosecurity/driver:my-tag") from an OCI registry, using the same
+// oras-go/oras machinery already used elsewhere in falcoctl for plugins and rulesfiles.
+// Every blob's digest is verified against the manifest by oras.Copy itself, so no further
+// checksum verification is required for this path.
+func pullOCIDriver(ctx context.Context, ref string) ([]byte, error) {
+	repo, err := newOCIRepository(ref)
+	if err != nil {
+		return nil, err
+	}
+	return fetchOCIArtifactLayer(ctx, repo, memory.New(), repo.Reference.Reference)
+}
+
+const (
+	// mirrorProbeTimeout bounds each individual probe attempt against a mirror.
+	mirrorProbeTimeout = 10 * time.Second
+	// mirrorProbeRetries is the number of attempts made against a single mirror,
+	// backing off exponentially between them, before it is given up on.
+	mirrorProbeRetries = 3
+	// mirrorProbeBackoff is the delay before the first retry of a mirror probe.
+	mirrorProbeBackoff = 250 * time.Millisecond
+)
+
+// mirror pairs a configured repo with the resolved location to fetch from: an HTTPS
+// URL, or an OCI reference when repo.URL uses the "oci://"/"registry://" scheme.
+type mirror struct {
+	repo     RepoConfig
+	location string
+	isOCI    bool
+}
+
+// probeMirror checks that m is actually worth downloading from, retrying any failed
+// attempt with exponential backoff. For HTTPS mirrors this is a ranged GET that must
+// come back 200 or 206 with a plausible (positive) artifact size; for OCI mirrors it is
+// a manifest resolve, the registry equivalent of a HEAD check.
+func probeMirror(ctx context.Context, m mirror) error {
+	var lastErr error
+	backoff := mirrorProbeBackoff
+	for attempt := 0; attempt < mirrorProbeRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, mirrorProbeTimeout)
+		if m.isOCI {
+			lastErr = probeOCIMirror(reqCtx, m.location)
+		} else {
+			lastErr = probeHTTPMirror(reqCtx, m.location)
+		}
+		cancel()
+		if lastErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return lastErr
+}
+
+// probeHTTPMirror issues a single-byte ranged GET rather than a HEAD, since some file
+// hosts/CDNs serving driver artifacts reject or mishandle HEAD even though GET works. It
+// also checks that the mirror reports a plausible (positive) size for the artifact, so a
+// mirror serving a truncated or zero-byte 200 response doesn't win the race like a real one.
+func probeHTTPMirror(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	size, err := mirrorArtifactSize(resp)
+	if err != nil {
+		return fmt.Errorf("unusable response from %s: %w", url, err)
+	}
+	if size <= 0 {
+		return fmt.Errorf("unusable response from %s: non-positive size %d", url, size)
+	}
+	return nil
+}
+
+// mirrorArtifactSize extracts the full artifact size from a ranged-GET probe response:
+// the total from Content-Range when the mirror honored the range (206 Partial Content),
+// or Content-Length when it ignored the range and served the whole body anyway (200 OK).
+func mirrorArtifactSize(resp *http.Response) (int64, error) {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		contentRange := resp.Header.Get("Content-Range")
+		idx := strings.LastIndex(contentRange, "/")
+		if idx == -1 || idx == len(contentRange)-1 {
+			return 0, fmt.Errorf("missing total size in Content-Range %q", contentRange)
+		}
+		return strconv.ParseInt(contentRange[idx+1:], 10, 64)
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	default:
+		return 0, fmt.Errorf("status %d", resp.StatusCode)
+	}
+}
+
+func probeOCIMirror(ctx context.Context, ref string) error {
+	repo, err := newOCIRepository(ref)
+	if err != nil {
+		return err
+	}
+	_, err = repo.Resolve(ctx, repo.Reference.Reference)
+	return err
+}
+
+// fetchMirror streams the artifact from the already-probed mirror m into memory.
+func fetchMirror(ctx context.Context, m mirror) ([]byte, error) {
+	if m.isOCI {
+		// The blob's digest is verified against the manifest by oras.Copy itself, so no
+		// further checksum verification is required for this path.
+		return pullOCIDriver(ctx, m.location)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, m.location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unable to download %s: status %d", m.location, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyChecksum(ctx, m.location, data); err != nil {
+		return nil, err
+	}
+	if m.repo.MinisignPublicKey != "" {
+		if err = verifyMinisignSignature(ctx, m.location, data, m.repo.MinisignPublicKey); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// raceMirrors probes every mirror concurrently and returns the first one that looks
+// viable, cancelling the in-flight probes against every other mirror as soon as a
+// winner is found.
+func raceMirrors(ctx context.Context, printer *output.Printer, mirrors []mirror) (mirror, error) {
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		m   mirror
+		err error
+	}
+	results := make(chan outcome, len(mirrors))
+	for _, m := range mirrors {
+		m := m
+		go func() {
+			results <- outcome{m: m, err: probeMirror(raceCtx, m)}
+		}()
+	}
+
+	var firstErr error
+	for range mirrors {
+		res := <-results
+		if res.err == nil {
+			cancel()
+			return res.m, nil
+		}
+		printer.Logger.Warn("Mirror did not respond, trying others.", printer.Logger.Args("location", res.m.location, "err", res.err))
+		if firstErr == nil {
+			firstErr = res.err
+		}
+	}
+	return mirror{}, firstErr
+}
+
+// Download will try to download drivers for a distro trying specified repos. All
+// remaining repos are probed concurrently (bounded by len(repos), since each probe is a
+// single lightweight request) and the first one to answer wins; the others are cancelled
+// via ctx so a slow or unreachable mirror can no longer stall the whole call. A repo
+// whose URL has an "oci://" or "registry://" scheme is pulled from an OCI registry
+// instead of plain HTTPS. Every HTTPS artifact is verified against its published
+// SHA-256 checksum (and, if the repo carries a MinisignPublicKey, its minisign
+// signature) before being written to disk; if that verification (or the fetch itself)
+// fails, that mirror is dropped and the remaining ones race again, same as the old
+// sequential fallback.
 //
 //nolint:gocritic // the method shall not be able to modify kr
 func Download(ctx context.Context,
@@ -189,7 +584,7 @@ func Download(ctx context.Context,
 	kr kernelrelease.KernelRelease,
 	driverName string,
 	driverType drivertype.DriverType,
-	driverVer string, repos []string,
+	driverVer string, repos []RepoConfig,
 ) (string, error) {
 	driverFileName := toFilename(d, &kr, driverName, driverType)
 	// Skip if existent
@@ -199,30 +594,46 @@ func Download(ctx context.Context,
 		return destination, nil
 	}
 
-	// Try to download from any specified repository,
-	// stopping at first successful http GET.
+	mirrors := make([]mirror, 0, len(repos))
 	for _, repo := range repos {
-		url := toURL(repo, driverVer, driverFileName, kr.Architecture.ToNonDeb())
-		printer.Logger.Info("Trying to download a driver.", printer.Logger.Args("url", url))
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-		if err != nil {
-			printer.Logger.Warn("Error creating http request.", printer.Logger.Args("err", err))
+		if registry, ok := ociReference(repo.URL); ok {
+			ref := fmt.Sprintf("%s:%s", registry, toOCITag(driverFileName))
+			mirrors = append(mirrors, mirror{repo: repo, location: ref, isOCI: true})
 			continue
 		}
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil || resp.StatusCode != 200 {
-			if err == nil {
-				_ = resp.Body.Close()
-			}
-			printer.Logger.Warn("Error GETting url.", printer.Logger.Args("err", err))
-			continue
+		url := toURL(repo.URL, driverVer, driverFileName, kr.Architecture.ToNonDeb())
+		mirrors = append(mirrors, mirror{repo: repo, location: url})
+	}
+	for len(mirrors) > 0 {
+		winner, err := raceMirrors(ctx, printer, mirrors)
+		if err != nil {
+			return destination, fmt.Errorf("unable to find a prebuilt driver: %w", err)
+		}
+		printer.Logger.Info("Downloading driver from the fastest responding mirror.", printer.Logger.Args("location", winner.location))
+		data, err := fetchMirror(ctx, winner)
+		if err == nil {
+			return destination, copyDataToLocalPath(destination, bytes.NewReader(data))
 		}
-		return destination, copyDataToLocalPath(destination, resp.Body)
+		printer.Logger.Warn("Driver fetch or verification failed, racing remaining mirrors.", printer.Logger.Args("location", winner.location, "err", err))
+		mirrors = removeMirror(mirrors, winner)
 	}
 	return destination, fmt.Errorf("unable to find a prebuilt driver")
 }
 
+func removeMirror(mirrors []mirror, loser mirror) []mirror {
+	remaining := make([]mirror, 0, len(mirrors)-1)
+	for _, m := range mirrors {
+		if m.location != loser.location {
+			remaining = append(remaining, m)
+		}
+	}
+	return remaining
+}
+
+// customizeDownloadKernelSrcBuild finishes preparing a freshly downloaded kernel tree
+// for out-of-tree module builds. It is meant to be called right after downloadKernelSrc
+// by a distro's DownloadKernelSources implementation, never on a tree that was already
+// prepared by some other means.
 func customizeDownloadKernelSrcBuild(printer *output.Printer, kr *kernelrelease.KernelRelease) error {
 	printer.Logger.Info("Configuring kernel.")
 	if kr.Extraversion != "" {
@@ -238,6 +649,17 @@ func customizeDownloadKernelSrcBuild(printer *output.Printer, kr *kernelrelease.
 	return err
 }
 
+// ResolveKernelConfig locates a usable kernel .config for kr, trying /proc/config.gz,
+// the /boot and ostree-boot configs (first as given, then under hostRoot), and
+// /lib/modules/<kr>/config, which is not looked up under hostRoot. It is
+// exposed as its own phase, alongside Distro.DownloadKernelSources and Build, so callers
+// (e.g. a `falcoctl driver prepare` subcommand, which does not yet exist in this package)
+// can resolve -- and fail fast on a missing -- kernel config before spending time
+// fetching kernel sources or building.
+func ResolveKernelConfig(printer *output.Printer, kr kernelrelease.KernelRelease, hostRoot string) (string, error) {
+	return getKernelConfig(printer, &kr, hostRoot)
+}
+
 func getKernelConfig(printer *output.Printer, kr *kernelrelease.KernelRelease, hostRoot string) (string, error) {
 	bootConfig := fmt.Sprintf("/boot/config-%s", kr.String())
 	hrBootConfig := fmt.Sprintf("%s%s", hostRoot, bootConfig)
@@ -263,6 +685,11 @@ func getKernelConfig(printer *output.Printer, kr *kernelrelease.KernelRelease, h
 	return "", fmt.Errorf("cannot find kernel config")
 }
 
+// downloadKernelSrc fetches and extracts the kernel headers/sources at url, then copies
+// the kernel config found for kr into .config in the current working directory. It is
+// the shared building block that
+// distro-specific DownloadKernelSources implementations call into; callers outside this
+// package should go through Distro.DownloadKernelSources instead.
 func downloadKernelSrc(ctx context.Context,
 	printer *output.Printer,
 	kr *kernelrelease.KernelRelease,
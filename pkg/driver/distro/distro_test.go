@@ -0,0 +1,393 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright (C) 2023 The Falco Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package driverdistro
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content/memory"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("driver bytes")
+
+	tests := []struct {
+		name      string
+		sidecar   string
+		status    int
+		wantError bool
+	}{
+		{name: "matching checksum", sidecar: sha256Hex(data), status: http.StatusOK, wantError: false},
+		{name: "mismatched checksum", sidecar: sha256Hex([]byte("other bytes")), status: http.StatusOK, wantError: true},
+		{name: "empty sidecar body", sidecar: "", status: http.StatusOK, wantError: true},
+		{name: "whitespace-only sidecar body", sidecar: "   \n", status: http.StatusOK, wantError: true},
+		{name: "sidecar not found", sidecar: "", status: http.StatusNotFound, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.sidecar))
+			}))
+			defer srv.Close()
+
+			err := verifyChecksum(context.Background(), srv.URL, data)
+			if tt.wantError && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// minisignKeyPair is a throwaway minisign-format key pair, built by hand from a
+// stdlib ed25519 key so tests can sign without shelling out to the minisign CLI. It
+// uses minisign's legacy (non-prehashed) "Ed" signature algorithm, which signs the
+// message directly instead of its BLAKE2b-512 hash.
+type minisignKeyPair struct {
+	keyID [8]byte
+	pub   ed25519.PublicKey
+	priv  ed25519.PrivateKey
+}
+
+func generateMinisignKeyPair(t *testing.T) minisignKeyPair {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return minisignKeyPair{keyID: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}, pub: pub, priv: priv}
+}
+
+// publicKey returns the base64-encoded public key blob, in the same form minisign
+// prints on the second line of a `minisign -G` generated public key file.
+func (k minisignKeyPair) publicKey() string {
+	blob := append([]byte{'E', 'd'}, k.keyID[:]...)
+	blob = append(blob, k.pub...)
+	return base64.StdEncoding.EncodeToString(blob)
+}
+
+// sign builds a minisign ".minisig" signature file for data.
+func (k minisignKeyPair) sign(data []byte) []byte {
+	sigBlob := append([]byte{'E', 'd'}, k.keyID[:]...)
+	sigBlob = append(sigBlob, ed25519.Sign(k.priv, data)...)
+
+	trustedComment := "timestamp:0"
+	globalSig := ed25519.Sign(k.priv, append(append([]byte{}, sigBlob...), []byte(trustedComment)...))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "untrusted comment: signature from minisign secret key\n")
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(sigBlob))
+	fmt.Fprintf(&out, "trusted comment: %s\n", trustedComment)
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(globalSig))
+	return out.Bytes()
+}
+
+func TestVerifyMinisignSignature(t *testing.T) {
+	data := []byte("driver bytes")
+	key := generateMinisignKeyPair(t)
+	validSig := key.sign(data)
+
+	otherKey := generateMinisignKeyPair(t)
+
+	tests := []struct {
+		name      string
+		sig       []byte
+		publicKey string
+		status    int
+		wantError bool
+	}{
+		{name: "valid signature", sig: validSig, publicKey: key.publicKey(), status: http.StatusOK, wantError: false},
+		{name: "tampered data", sig: key.sign([]byte("other bytes")), publicKey: key.publicKey(), status: http.StatusOK, wantError: true},
+		{
+			name:      "wrong public key",
+			sig:       validSig,
+			publicKey: otherKey.publicKey(),
+			status:    http.StatusOK,
+			wantError: true,
+		},
+		{name: "invalid public key encoding", sig: validSig, publicKey: "not-base64!!", status: http.StatusOK, wantError: true},
+		{name: "signature not found", sig: nil, publicKey: key.publicKey(), status: http.StatusNotFound, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write(tt.sig)
+			}))
+			defer srv.Close()
+
+			err := verifyMinisignSignature(context.Background(), srv.URL, data, tt.publicKey)
+			if tt.wantError && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestOciReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		repo      string
+		wantRepo  string
+		wantIsOCI bool
+	}{
+		{name: "oci scheme", repo: "oci://ghcr.io/falcosecurity/driver", wantRepo: "ghcr.io/falcosecurity/driver", wantIsOCI: true},
+		{name: "registry scheme", repo: "registry://ghcr.io/falcosecurity/driver", wantRepo: "ghcr.io/falcosecurity/driver", wantIsOCI: true},
+		{name: "https repo", repo: "https://download.falco.org/driver", wantRepo: "", wantIsOCI: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ociReference(tt.repo)
+			if ok != tt.wantIsOCI {
+				t.Fatalf("ociReference(%q) ok = %v, want %v", tt.repo, ok, tt.wantIsOCI)
+			}
+			if ok && got != tt.wantRepo {
+				t.Fatalf("ociReference(%q) = %q, want %q", tt.repo, got, tt.wantRepo)
+			}
+		})
+	}
+}
+
+func TestToOCITag(t *testing.T) {
+	tests := []struct {
+		name           string
+		driverFileName string
+		want           string
+	}{
+		{name: "already valid", driverFileName: "falco_ubuntu_5.15.0.ko", want: "falco_ubuntu_5.15.0.ko"},
+		{name: "slashes replaced", driverFileName: "falco/ubuntu/5.15.0.ko", want: "falco-ubuntu-5.15.0.ko"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toOCITag(tt.driverFileName); got != tt.want {
+				t.Fatalf("toOCITag(%q) = %q, want %q", tt.driverFileName, got, tt.want)
+			}
+		})
+	}
+}
+
+// pushOCIArtifact pushes a single-layer OCI artifact made of layerData into store under
+// tag, the same shape a real registry push of a driver artifact would produce.
+func pushOCIArtifact(ctx context.Context, t *testing.T, store *memory.Store, tag string, layerData []byte) ocispec.Descriptor {
+	t.Helper()
+
+	layerDesc := ocispec.Descriptor{
+		MediaType: "application/vnd.falcosecurity.driver.layer.v1",
+		Digest:    digest.FromBytes(layerData),
+		Size:      int64(len(layerData)),
+	}
+	if err := store.Push(ctx, layerDesc, bytes.NewReader(layerData)); err != nil {
+		t.Fatalf("pushing layer: %v", err)
+	}
+
+	configData := []byte("{}")
+	configDesc := ocispec.Descriptor{
+		MediaType: "application/vnd.oci.empty.v1+json",
+		Digest:    digest.FromBytes(configData),
+		Size:      int64(len(configData)),
+	}
+	if err := store.Push(ctx, configDesc, bytes.NewReader(configData)); err != nil {
+		t.Fatalf("pushing config: %v", err)
+	}
+
+	manifest := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    configDesc,
+		Layers:    []ocispec.Descriptor{layerDesc},
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatalf("marshalling manifest: %v", err)
+	}
+	manifestDesc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifestData),
+		Size:      int64(len(manifestData)),
+	}
+	if err := store.Push(ctx, manifestDesc, bytes.NewReader(manifestData)); err != nil {
+		t.Fatalf("pushing manifest: %v", err)
+	}
+	if err := store.Tag(ctx, manifestDesc, tag); err != nil {
+		t.Fatalf("tagging manifest: %v", err)
+	}
+	return manifestDesc
+}
+
+func TestFetchOCIArtifactLayer(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	layerData := []byte("driver bytes")
+	pushOCIArtifact(ctx, t, src, "my-tag", layerData)
+
+	dst := memory.New()
+	got, err := fetchOCIArtifactLayer(ctx, src, dst, "my-tag")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, layerData) {
+		t.Fatalf("fetchOCIArtifactLayer() = %q, want %q", got, layerData)
+	}
+}
+
+func TestProbeHTTPMirror(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		contentRange  string
+		contentLength string
+		wantError     bool
+	}{
+		{name: "200 ignoring range, plausible size", status: http.StatusOK, contentLength: "4096", wantError: false},
+		{name: "206 partial content, plausible size", status: http.StatusPartialContent, contentRange: "bytes 0-0/4096", wantError: false},
+		{name: "200 with zero-byte body", status: http.StatusOK, contentLength: "0", wantError: true},
+		{name: "206 with missing total in Content-Range", status: http.StatusPartialContent, contentRange: "bytes 0-0/*", wantError: true},
+		{name: "404 not found", status: http.StatusNotFound, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotRange string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotRange = r.Header.Get("Range")
+				if tt.contentRange != "" {
+					w.Header().Set("Content-Range", tt.contentRange)
+				}
+				if tt.contentLength != "" {
+					w.Header().Set("Content-Length", tt.contentLength)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			err := probeHTTPMirror(context.Background(), srv.URL)
+			if tt.wantError && err == nil {
+				t.Fatalf("expected an error, got nil")
+			}
+			if !tt.wantError && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotRange != "bytes=0-0" {
+				t.Fatalf("probeHTTPMirror sent Range %q, want %q", gotRange, "bytes=0-0")
+			}
+		})
+	}
+}
+
+func TestProbeMirror_RetriesBeforeSucceeding(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&attempts, 1)
+		if n < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Length", "4096")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	err := probeMirror(context.Background(), mirror{location: srv.URL})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(&attempts); got < 2 {
+		t.Fatalf("expected probeMirror to retry, only saw %d attempt(s)", got)
+	}
+}
+
+func TestProbeMirror_GivesUpAfterMaxRetries(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	err := probeMirror(context.Background(), mirror{location: srv.URL})
+	if err == nil {
+		t.Fatalf("expected an error after exhausting retries, got nil")
+	}
+	if got := atomic.LoadInt32(&attempts); got != mirrorProbeRetries {
+		t.Fatalf("probeMirror made %d attempt(s), want %d", got, mirrorProbeRetries)
+	}
+}
+
+func TestProbeMirror_StopsEarlyOnContextCancellation(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := probeMirror(ctx, mirror{location: srv.URL})
+	if err == nil {
+		t.Fatalf("expected an error for a cancelled context, got nil")
+	}
+	if elapsed := time.Since(start); elapsed >= mirrorProbeBackoff {
+		t.Fatalf("probeMirror waited %v on a cancelled context, expected to return immediately", elapsed)
+	}
+}
+
+func TestRemoveMirror(t *testing.T) {
+	a := mirror{location: "a"}
+	b := mirror{location: "b"}
+	c := mirror{location: "c"}
+
+	got := removeMirror([]mirror{a, b, c}, b)
+	want := []string{"a", "c"}
+	if len(got) != len(want) {
+		t.Fatalf("removeMirror returned %d mirrors, want %d", len(got), len(want))
+	}
+	for i, m := range got {
+		if m.location != want[i] {
+			t.Fatalf("removeMirror()[%d] = %q, want %q", i, m.location, want[i])
+		}
+	}
+}